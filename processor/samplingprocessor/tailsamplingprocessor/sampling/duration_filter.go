@@ -16,59 +16,317 @@ package sampling
 
 import (
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
 	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/collector/consumer/pdata"
 
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/golang/protobuf/ptypes/timestamp"
+	lru "github.com/hashicorp/golang-lru/simplelru"
 	"go.uber.org/zap"
 )
 
+// defaultSecondChanceCacheSize bounds the number of NotSampled trace IDs kept
+// around for reconsideration if late-arriving spans might flip the decision.
+const defaultSecondChanceCacheSize = 10000
+
+// defaultSecondChanceTTL is how long a NotSampled trace ID remains eligible
+// for reconsideration after its original decision.
+const defaultSecondChanceTTL = 10 * time.Minute
+
+// StatusCode is a simplified view of a span's status, independent of the
+// underlying proto's status code representation, used for filtering criteria.
+type StatusCode int32
+
+const (
+	// StatusCodeUnset matches spans that carry no status at all.
+	StatusCodeUnset StatusCode = iota
+	// StatusCodeOK matches spans whose status code reports success.
+	StatusCodeOK
+	// StatusCodeError matches spans whose status code reports a failure.
+	StatusCodeError
+)
+
+// LatencyBucket defines a trace-duration range that is sampled at its own
+// percentage, independent of minDurationMicros/maxDurationMicros.
+type LatencyBucket struct {
+	MinDurationMicros  int64
+	MaxDurationMicros  int64
+	SamplingPercentage float64
+}
+
+func (b LatencyBucket) contains(durationMicros int64) bool {
+	return durationMicros >= b.MinDurationMicros && durationMicros < b.MaxDurationMicros
+}
+
 type spanPropertiesFilter struct {
 	operationRe       *regexp.Regexp
 	minDurationMicros *int64
+	maxDurationMicros *int64
+	latencyBuckets    []LatencyBucket
 	minNumberOfSpans  *int
+	attributeFilters  map[string]*regexp.Regexp
+	statusCodeFilters []StatusCode
+	spanKindFilters   []tracepb.Span_SpanKind
 	logger            *zap.Logger
+
+	secondChanceTTL      time.Duration
+	secondChanceMu       sync.Mutex
+	secondChanceCache    *lru.LRU
+	secondChanceRemoving bool // guarded by secondChanceMu; suppresses the evict callback for intentional removals
+
+	secondChanceEvaluations int64
+	secondChanceFlipped     int64
+	secondChanceEvictions   int64
+}
+
+// secondChanceEntry tracks when a NotSampled decision was made for a trace ID
+// still held in the second-chance backlog, so reconsideration can honor the TTL.
+type secondChanceEntry struct {
+	decidedAt time.Time
 }
 
 var _ PolicyEvaluator = (*spanPropertiesFilter)(nil)
 
+// SpanPropertiesFilterConfig bundles the criteria accepted by
+// NewSpanPropertiesFilter. It exists so that same-typed, adjacent fields
+// (e.g. the min/max duration bounds) are set by name rather than by
+// position, where a transposition at the call site would otherwise compile
+// silently.
+type SpanPropertiesFilterConfig struct {
+	OperationNamePattern *string
+	MinDurationMicros    *int64
+	MaxDurationMicros    *int64
+	LatencyBuckets       []LatencyBucket
+	MinNumberOfSpans     *int
+	AttributeFilters     map[string]string
+	StatusCodeFilters    []StatusCode
+	SpanKindFilters      []tracepb.Span_SpanKind
+
+	// SecondChanceCacheSize and SecondChanceTTL configure the backlog used by
+	// EvaluateSecondChance. They default to defaultSecondChanceCacheSize and
+	// defaultSecondChanceTTL when nil.
+	SecondChanceCacheSize *int
+	SecondChanceTTL       *time.Duration
+}
+
 // NewSpanPropertiesFilter creates a policy evaluator that samples all traces with
 // the specified criteria
-func NewSpanPropertiesFilter(logger *zap.Logger, operationNamePattern *string, minDurationMicros *int64, minNumberOfSpans *int) (PolicyEvaluator, error) {
+func NewSpanPropertiesFilter(logger *zap.Logger, cfg SpanPropertiesFilterConfig) (PolicyEvaluator, error) {
 	var operationRe *regexp.Regexp
-	if operationNamePattern != nil {
+	if cfg.OperationNamePattern != nil {
 		var err error
-		operationRe, err = regexp.Compile(*operationNamePattern)
+		operationRe, err = regexp.Compile(*cfg.OperationNamePattern)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if operationNamePattern == nil && minDurationMicros == nil && minNumberOfSpans == nil {
+	var attributeRes map[string]*regexp.Regexp
+	if len(cfg.AttributeFilters) > 0 {
+		attributeRes = make(map[string]*regexp.Regexp, len(cfg.AttributeFilters))
+		for key, pattern := range cfg.AttributeFilters {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			attributeRes[key] = re
+		}
+	}
+
+	if cfg.MinDurationMicros != nil && cfg.MaxDurationMicros != nil && *cfg.MinDurationMicros > *cfg.MaxDurationMicros {
+		return nil, fmt.Errorf("minDurationMicros (%d) must not be greater than maxDurationMicros (%d)", *cfg.MinDurationMicros, *cfg.MaxDurationMicros)
+	}
+
+	for _, bucket := range cfg.LatencyBuckets {
+		if bucket.MinDurationMicros > bucket.MaxDurationMicros {
+			return nil, fmt.Errorf("latency bucket min (%d) must not be greater than max (%d)", bucket.MinDurationMicros, bucket.MaxDurationMicros)
+		}
+	}
+
+	if cfg.OperationNamePattern == nil && cfg.MinDurationMicros == nil && cfg.MaxDurationMicros == nil && cfg.MinNumberOfSpans == nil &&
+		len(cfg.AttributeFilters) == 0 && len(cfg.StatusCodeFilters) == 0 && len(cfg.SpanKindFilters) == 0 && len(cfg.LatencyBuckets) == 0 {
 		return nil, errors.New("at least one property must be defined")
 	}
 
-	return &spanPropertiesFilter{
+	cacheSize := defaultSecondChanceCacheSize
+	if cfg.SecondChanceCacheSize != nil {
+		cacheSize = *cfg.SecondChanceCacheSize
+	}
+	ttl := defaultSecondChanceTTL
+	if cfg.SecondChanceTTL != nil {
+		ttl = *cfg.SecondChanceTTL
+	}
+
+	df := &spanPropertiesFilter{
 		operationRe:       operationRe,
-		minDurationMicros: minDurationMicros,
-		minNumberOfSpans:  minNumberOfSpans,
+		minDurationMicros: cfg.MinDurationMicros,
+		maxDurationMicros: cfg.MaxDurationMicros,
+		latencyBuckets:    cfg.LatencyBuckets,
+		minNumberOfSpans:  cfg.MinNumberOfSpans,
+		attributeFilters:  attributeRes,
+		statusCodeFilters: cfg.StatusCodeFilters,
+		spanKindFilters:   cfg.SpanKindFilters,
 		logger:            logger,
-	}, nil
+		secondChanceTTL:   ttl,
+	}
+
+	cache, err := lru.NewLRU(cacheSize, func(_ interface{}, _ interface{}) {
+		// Remove() is also routed through this callback; removeSecondChanceLocked
+		// sets secondChanceRemoving around those intentional removals so only
+		// true capacity-driven evictions are counted here.
+		if !df.secondChanceRemoving {
+			atomic.AddInt64(&df.secondChanceEvictions, 1)
+			recordSecondChanceMeasure(statSecondChanceEvictions)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	df.secondChanceCache = cache
+
+	return df, nil
+}
+
+// removeSecondChanceLocked removes key from the second-chance backlog without
+// counting the removal as a capacity eviction. Callers must hold secondChanceMu.
+func (df *spanPropertiesFilter) removeSecondChanceLocked(key string) {
+	df.secondChanceRemoving = true
+	df.secondChanceCache.Remove(key)
+	df.secondChanceRemoving = false
+}
+
+// SecondChanceEvaluations returns the number of times a previously NotSampled
+// trace was re-evaluated by EvaluateSecondChance while still in the
+// backlog and within its TTL. It mirrors the sampling_second_chance_evaluations OpenCensus view.
+func (df *spanPropertiesFilter) SecondChanceEvaluations() int64 {
+	return atomic.LoadInt64(&df.secondChanceEvaluations)
+}
+
+// SecondChanceFlipped returns the number of traces whose decision flipped from
+// NotSampled to Sampled during second-chance reconsideration. It mirrors the
+// sampling_second_chance_flipped OpenCensus view.
+func (df *spanPropertiesFilter) SecondChanceFlipped() int64 {
+	return atomic.LoadInt64(&df.secondChanceFlipped)
+}
+
+// SecondChanceEvictions returns the number of trace IDs dropped from the
+// second-chance backlog due to LRU capacity. It mirrors the
+// sampling_second_chance_evictions OpenCensus view.
+func (df *spanPropertiesFilter) SecondChanceEvictions() int64 {
+	return atomic.LoadInt64(&df.secondChanceEvictions)
+}
+
+// bucketSamplingDecision deterministically decides, based on a hash of the
+// trace ID, whether a trace falling in a latency bucket should be sampled at
+// that bucket's percentage. The same trace ID always yields the same
+// decision, so retries of the same trace are not sampled inconsistently.
+func bucketSamplingDecision(traceID pdata.TraceID, samplingPercentage float64) bool {
+	if samplingPercentage >= 100 {
+		return true
+	}
+	if samplingPercentage <= 0 {
+		return false
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write(traceID.Bytes())
+	threshold := uint32(float64(math.MaxUint32) * (samplingPercentage / 100))
+	return h.Sum32() <= threshold
+}
+
+// attributeValueToString renders a span attribute's string/int/bool value for
+// regex matching. Attribute kinds that cannot be represented as text (e.g.
+// double values) are skipped by the caller.
+func attributeValueToString(value *tracepb.AttributeValue) (string, bool) {
+	switch v := value.GetValue().(type) {
+	case *tracepb.AttributeValue_StringValue:
+		return v.StringValue.GetValue(), true
+	case *tracepb.AttributeValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10), true
+	case *tracepb.AttributeValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue), true
+	default:
+		return "", false
+	}
+}
+
+// statusCodeOf maps a span's status onto the simplified StatusCode used by
+// this filter, treating an absent status as StatusCodeUnset.
+func statusCodeOf(span *tracepb.Span) StatusCode {
+	if span.Status == nil {
+		return StatusCodeUnset
+	}
+	if span.Status.Code == 0 {
+		return StatusCodeOK
+	}
+	return StatusCodeError
 }
 
 // OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
 // after the sampling decision was already taken for the trace.
 // This gives the evaluator a chance to log any message/metrics and/or update any
 // related internal state.
+//
+// The interface does not carry the trace ID of the late spans, so the actual
+// reconsideration of a NotSampled decision happens in EvaluateSecondChance,
+// which the caller is expected to invoke (with the trace ID it already
+// tracks) once the late spans have been merged into the trace. This hook is
+// limited to logging for observability.
 func (df *spanPropertiesFilter) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	if earlyDecision == NotSampled {
+		df.logger.Debug("late-arriving spans for a previously not-sampled trace", zap.Int("num_spans", len(spans)))
+	}
 	return nil
 }
 
-// EvaluateSecondChance looks at the trace again and if it can/cannot be fit, returns a SamplingDecision
-func (df *spanPropertiesFilter) EvaluateSecondChance(_ pdata.TraceID, trace *TraceData) (Decision, error) {
-	return NotSampled, nil
+// EvaluateSecondChance re-evaluates a trace that was previously NotSampled, if
+// it is still within its second-chance window, and returns an updated
+// SamplingDecision. A trace that is still NotSampled after reconsideration
+// stays in the backlog and can be reconsidered again; a flip to Sampled, or
+// TTL expiry, removes it from the backlog.
+func (df *spanPropertiesFilter) EvaluateSecondChance(traceID pdata.TraceID, trace *TraceData) (Decision, error) {
+	key := string(traceID.Bytes())
+
+	df.secondChanceMu.Lock()
+	entryRaw, ok := df.secondChanceCache.Get(key)
+	df.secondChanceMu.Unlock()
+	if !ok {
+		return NotSampled, nil
+	}
+
+	entry := entryRaw.(secondChanceEntry)
+	if time.Since(entry.decidedAt) > df.secondChanceTTL {
+		df.secondChanceMu.Lock()
+		df.removeSecondChanceLocked(key)
+		df.secondChanceMu.Unlock()
+		return NotSampled, nil
+	}
+
+	atomic.AddInt64(&df.secondChanceEvaluations, 1)
+	recordSecondChanceMeasure(statSecondChanceEvaluations)
+
+	decision, err := df.evaluateCriteria(traceID, trace)
+	if err != nil {
+		return decision, err
+	}
+
+	if decision == Sampled {
+		df.secondChanceMu.Lock()
+		df.removeSecondChanceLocked(key)
+		df.secondChanceMu.Unlock()
+		atomic.AddInt64(&df.secondChanceFlipped, 1)
+		recordSecondChanceMeasure(statSecondChanceFlipped)
+	}
+
+	return decision, nil
 }
 
 func tsToMicros(ts *timestamp.Timestamp) int64 {
@@ -76,16 +334,41 @@ func tsToMicros(ts *timestamp.Timestamp) int64 {
 }
 
 // Evaluate looks at the trace data and returns a corresponding SamplingDecision.
-func (df *spanPropertiesFilter) Evaluate(_ pdata.TraceID, trace *TraceData) (Decision, error) {
+// NotSampled traces are kept in a bounded backlog so that EvaluateSecondChance
+// can reconsider them if late-arriving spans change the outcome.
+func (df *spanPropertiesFilter) Evaluate(traceID pdata.TraceID, trace *TraceData) (Decision, error) {
+	decision, err := df.evaluateCriteria(traceID, trace)
+	if err != nil {
+		return decision, err
+	}
+
+	if decision == NotSampled {
+		df.secondChanceMu.Lock()
+		df.secondChanceCache.Add(string(traceID.Bytes()), secondChanceEntry{decidedAt: time.Now()})
+		df.secondChanceMu.Unlock()
+	}
+
+	return decision, nil
+}
+
+// evaluateCriteria runs the filter's configured criteria against the trace's
+// currently received batches, shared by Evaluate and EvaluateSecondChance.
+func (df *spanPropertiesFilter) evaluateCriteria(traceID pdata.TraceID, trace *TraceData) (Decision, error) {
 	trace.Lock()
 	batches := trace.ReceivedBatches
 	trace.Unlock()
 
+	needsDuration := df.minDurationMicros != nil || df.maxDurationMicros != nil || len(df.latencyBuckets) > 0
+
 	matchingOperationFound := false
 	spanCount := 0
 	minStartTime := int64(0)
 	maxEndTime := int64(0)
 
+	attributesFound := make(map[string]bool, len(df.attributeFilters))
+	statusCodeFound := len(df.statusCodeFilters) == 0
+	spanKindFound := len(df.spanKindFilters) == 0
+
 	for _, batch := range batches {
 		spanCount += len(batch.Spans)
 
@@ -100,7 +383,7 @@ func (df *spanPropertiesFilter) Evaluate(_ pdata.TraceID, trace *TraceData) (Dec
 				}
 			}
 
-			if df.minDurationMicros != nil {
+			if needsDuration {
 				startTs := tsToMicros(span.StartTime)
 				endTs := tsToMicros(span.EndTime)
 
@@ -116,13 +399,51 @@ func (df *spanPropertiesFilter) Evaluate(_ pdata.TraceID, trace *TraceData) (Dec
 					}
 				}
 			}
+
+			if len(df.attributeFilters) > 0 && span.Attributes != nil {
+				for key, re := range df.attributeFilters {
+					if attributesFound[key] {
+						continue
+					}
+					attr, ok := span.Attributes.AttributeMap[key]
+					if !ok {
+						continue
+					}
+					if value, ok := attributeValueToString(attr); ok && re.MatchString(value) {
+						attributesFound[key] = true
+					}
+				}
+			}
+
+			if !statusCodeFound {
+				code := statusCodeOf(span)
+				for _, want := range df.statusCodeFilters {
+					if code == want {
+						statusCodeFound = true
+						break
+					}
+				}
+			}
+
+			if !spanKindFound {
+				for _, want := range df.spanKindFilters {
+					if span.Kind == want {
+						spanKindFound = true
+						break
+					}
+				}
+			}
 		}
 	}
 
 	operationNameConditionMet := true
 	minDurationConditionMet := true
+	maxDurationConditionMet := true
 	minSpanCountConditionMet := true
+	attributeConditionMet := true
+	latencyBucketConditionMet := true
 
+	traceDurationMicros := maxEndTime - minStartTime
 
 	if df.operationRe != nil {
 		operationNameConditionMet = matchingOperationFound
@@ -130,14 +451,40 @@ func (df *spanPropertiesFilter) Evaluate(_ pdata.TraceID, trace *TraceData) (Dec
 
 	if df.minDurationMicros != nil {
 		// Sanity check first
-		minDurationConditionMet = maxEndTime > minStartTime && maxEndTime-minStartTime >= *df.minDurationMicros
+		minDurationConditionMet = maxEndTime > minStartTime && traceDurationMicros >= *df.minDurationMicros
+	}
+
+	if df.maxDurationMicros != nil {
+		maxDurationConditionMet = maxEndTime > minStartTime && traceDurationMicros <= *df.maxDurationMicros
+	}
+
+	if len(df.latencyBuckets) > 0 {
+		latencyBucketConditionMet = false
+		if maxEndTime > minStartTime {
+			for _, bucket := range df.latencyBuckets {
+				if bucket.contains(traceDurationMicros) {
+					latencyBucketConditionMet = bucketSamplingDecision(traceID, bucket.SamplingPercentage)
+					break
+				}
+			}
+		}
 	}
 
 	if df.minNumberOfSpans != nil {
 		minSpanCountConditionMet = spanCount >= *df.minNumberOfSpans
 	}
 
-	if minDurationConditionMet && operationNameConditionMet && minSpanCountConditionMet {
+	if len(df.attributeFilters) > 0 {
+		for key := range df.attributeFilters {
+			if !attributesFound[key] {
+				attributeConditionMet = false
+				break
+			}
+		}
+	}
+
+	if minDurationConditionMet && maxDurationConditionMet && operationNameConditionMet && minSpanCountConditionMet &&
+		attributeConditionMet && statusCodeFound && spanKindFound && latencyBucketConditionMet {
 		return Sampled, nil
 	}
 
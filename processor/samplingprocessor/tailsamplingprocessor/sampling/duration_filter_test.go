@@ -0,0 +1,340 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+func spanWithTimes(startMicros, endMicros int64) *tracepb.Span {
+	return &tracepb.Span{
+		Name: &tracepb.TruncatableString{Value: "op"},
+		StartTime: &timestamp.Timestamp{
+			Seconds: startMicros / 1000000,
+			Nanos:   int32((startMicros % 1000000) * 1000),
+		},
+		EndTime: &timestamp.Timestamp{
+			Seconds: endMicros / 1000000,
+			Nanos:   int32((endMicros % 1000000) * 1000),
+		},
+	}
+}
+
+func newTraceData(spans ...*tracepb.Span) *TraceData {
+	return &TraceData{ReceivedBatches: []batch{{Spans: spans}}}
+}
+
+func traceIDFromByte(b byte) pdata.TraceID {
+	var raw [16]byte
+	raw[0] = b
+	return pdata.NewTraceID(raw)
+}
+
+func TestNewSpanPropertiesFilter_RequiresAtLeastOneProperty(t *testing.T) {
+	_, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{})
+	require.Error(t, err)
+}
+
+func TestEvaluate_OperationName(t *testing.T) {
+	pattern := "^foo$"
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{OperationNamePattern: &pattern})
+	require.NoError(t, err)
+
+	matching := spanWithTimes(0, 1)
+	matching.Name = &tracepb.TruncatableString{Value: "foo"}
+	nonMatching := spanWithTimes(0, 1)
+	nonMatching.Name = &tracepb.TruncatableString{Value: "bar"}
+
+	decision, err := filter.Evaluate(traceIDFromByte(1), newTraceData(matching))
+	require.NoError(t, err)
+	assert.Equal(t, Sampled, decision)
+
+	decision, err = filter.Evaluate(traceIDFromByte(2), newTraceData(nonMatching))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+}
+
+func TestEvaluate_AttributeFilters_ANDAcrossKeys(t *testing.T) {
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		AttributeFilters: map[string]string{
+			"http.method":      "^GET$",
+			"http.status_code": "^5\\d\\d$",
+		},
+	})
+	require.NoError(t, err)
+
+	methodSpan := spanWithTimes(0, 1)
+	methodSpan.Attributes = &tracepb.Span_Attributes{
+		AttributeMap: map[string]*tracepb.AttributeValue{
+			"http.method": {Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: "GET"}}},
+		},
+	}
+	statusSpan := spanWithTimes(1, 2)
+	statusSpan.Attributes = &tracepb.Span_Attributes{
+		AttributeMap: map[string]*tracepb.AttributeValue{
+			"http.status_code": {Value: &tracepb.AttributeValue_IntValue{IntValue: 500}},
+		},
+	}
+
+	// Only one of the two required attributes is present across the trace: NotSampled.
+	decision, err := filter.Evaluate(traceIDFromByte(1), newTraceData(methodSpan))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	// Both required attributes are present, each on a different span: Sampled.
+	decision, err = filter.Evaluate(traceIDFromByte(2), newTraceData(methodSpan, statusSpan))
+	require.NoError(t, err)
+	assert.Equal(t, Sampled, decision)
+}
+
+func TestEvaluate_StatusCodeFilter_ORWithinList(t *testing.T) {
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		StatusCodeFilters: []StatusCode{StatusCodeUnset, StatusCodeError},
+	})
+	require.NoError(t, err)
+
+	okSpan := spanWithTimes(0, 1)
+	okSpan.Status = &tracepb.Status{Code: 0}
+	errSpan := spanWithTimes(0, 1)
+	errSpan.Status = &tracepb.Status{Code: 2}
+
+	decision, err := filter.Evaluate(traceIDFromByte(1), newTraceData(okSpan))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	decision, err = filter.Evaluate(traceIDFromByte(2), newTraceData(okSpan, errSpan))
+	require.NoError(t, err)
+	assert.Equal(t, Sampled, decision)
+}
+
+func TestEvaluate_SpanKindFilter(t *testing.T) {
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		SpanKindFilters: []tracepb.Span_SpanKind{tracepb.Span_SERVER},
+	})
+	require.NoError(t, err)
+
+	clientSpan := spanWithTimes(0, 1)
+	clientSpan.Kind = tracepb.Span_CLIENT
+	serverSpan := spanWithTimes(0, 1)
+	serverSpan.Kind = tracepb.Span_SERVER
+
+	decision, err := filter.Evaluate(traceIDFromByte(1), newTraceData(clientSpan))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	decision, err = filter.Evaluate(traceIDFromByte(2), newTraceData(clientSpan, serverSpan))
+	require.NoError(t, err)
+	assert.Equal(t, Sampled, decision)
+}
+
+func TestEvaluate_CombinedCriteria(t *testing.T) {
+	pattern := "^foo$"
+	minSpans := 2
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		OperationNamePattern: &pattern,
+		MinNumberOfSpans:     &minSpans,
+		StatusCodeFilters:    []StatusCode{StatusCodeError},
+	})
+	require.NoError(t, err)
+
+	foo := spanWithTimes(0, 1)
+	foo.Name = &tracepb.TruncatableString{Value: "foo"}
+	errSpan := spanWithTimes(0, 1)
+	errSpan.Status = &tracepb.Status{Code: 3}
+
+	// Operation name matched, but there is no errored span and span count (1)
+	// is below minimum (2).
+	decision, err := filter.Evaluate(traceIDFromByte(1), newTraceData(foo))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	// All three criteria now satisfied.
+	decision, err = filter.Evaluate(traceIDFromByte(2), newTraceData(foo, errSpan))
+	require.NoError(t, err)
+	assert.Equal(t, Sampled, decision)
+}
+
+func TestNewSpanPropertiesFilter_RejectsMinGreaterThanMax(t *testing.T) {
+	min := int64(100)
+	max := int64(50)
+	_, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		MinDurationMicros: &min,
+		MaxDurationMicros: &max,
+	})
+	require.Error(t, err)
+}
+
+func TestEvaluate_MinAndMaxDuration(t *testing.T) {
+	min := int64(100)
+	max := int64(200)
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		MinDurationMicros: &min,
+		MaxDurationMicros: &max,
+	})
+	require.NoError(t, err)
+
+	tooShort := spanWithTimes(0, 50)
+	justRight := spanWithTimes(0, 150)
+	tooLong := spanWithTimes(0, 500)
+
+	decision, err := filter.Evaluate(traceIDFromByte(1), newTraceData(tooShort))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	decision, err = filter.Evaluate(traceIDFromByte(2), newTraceData(justRight))
+	require.NoError(t, err)
+	assert.Equal(t, Sampled, decision)
+
+	decision, err = filter.Evaluate(traceIDFromByte(3), newTraceData(tooLong))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+}
+
+func TestNewSpanPropertiesFilter_RejectsInvalidLatencyBucket(t *testing.T) {
+	_, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		LatencyBuckets: []LatencyBucket{{MinDurationMicros: 500, MaxDurationMicros: 100, SamplingPercentage: 10}},
+	})
+	require.Error(t, err)
+}
+
+func TestEvaluate_LatencyBuckets_DeterministicByTraceID(t *testing.T) {
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		LatencyBuckets: []LatencyBucket{
+			{MinDurationMicros: 0, MaxDurationMicros: 1000, SamplingPercentage: 100},
+			{MinDurationMicros: 1000, MaxDurationMicros: 2000, SamplingPercentage: 0},
+		},
+	})
+	require.NoError(t, err)
+
+	fast := spanWithTimes(0, 500)
+	slow := spanWithTimes(0, 1500)
+	traceID := traceIDFromByte(7)
+
+	// 100% bucket: always sampled.
+	decision, err := filter.Evaluate(traceID, newTraceData(fast))
+	require.NoError(t, err)
+	assert.Equal(t, Sampled, decision)
+
+	// 0% bucket: never sampled.
+	decision, err = filter.Evaluate(traceID, newTraceData(slow))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	// Same trace ID against the same bucket always yields the same decision.
+	decision2, err := filter.Evaluate(traceID, newTraceData(fast))
+	require.NoError(t, err)
+	assert.Equal(t, decision, NotSampled)
+	assert.Equal(t, Sampled, decision2)
+}
+
+func TestEvaluateSecondChance_FlipsOnLateArrivingSpans(t *testing.T) {
+	pattern := "^foo$"
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{OperationNamePattern: &pattern})
+	require.NoError(t, err)
+
+	traceID := traceIDFromByte(9)
+	bar := spanWithTimes(0, 1)
+	bar.Name = &tracepb.TruncatableString{Value: "bar"}
+
+	decision, err := filter.Evaluate(traceID, newTraceData(bar))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	foo := spanWithTimes(0, 1)
+	foo.Name = &tracepb.TruncatableString{Value: "foo"}
+
+	require.NoError(t, filter.OnLateArrivingSpans(decision, []*tracepb.Span{foo}))
+
+	decision, err = filter.EvaluateSecondChance(traceID, newTraceData(bar, foo))
+	require.NoError(t, err)
+	assert.Equal(t, Sampled, decision)
+
+	impl := filter.(*spanPropertiesFilter)
+	assert.Equal(t, int64(1), impl.SecondChanceEvaluations())
+	assert.Equal(t, int64(1), impl.SecondChanceFlipped())
+}
+
+func TestEvaluateSecondChance_UnknownTraceIDIsNotSampled(t *testing.T) {
+	pattern := "^foo$"
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{OperationNamePattern: &pattern})
+	require.NoError(t, err)
+
+	decision, err := filter.EvaluateSecondChance(traceIDFromByte(42), newTraceData())
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+}
+
+func TestEvaluateSecondChance_ExpiresAfterTTL(t *testing.T) {
+	pattern := "^foo$"
+	ttl := 10 * time.Millisecond
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		OperationNamePattern: &pattern,
+		SecondChanceTTL:      &ttl,
+	})
+	require.NoError(t, err)
+
+	traceID := traceIDFromByte(5)
+	bar := spanWithTimes(0, 1)
+	bar.Name = &tracepb.TruncatableString{Value: "bar"}
+
+	decision, err := filter.Evaluate(traceID, newTraceData(bar))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	time.Sleep(ttl * 3)
+
+	foo := spanWithTimes(0, 1)
+	foo.Name = &tracepb.TruncatableString{Value: "foo"}
+	decision, err = filter.EvaluateSecondChance(traceID, newTraceData(bar, foo))
+	require.NoError(t, err)
+	assert.Equal(t, NotSampled, decision)
+
+	// TTL expiry removes the entry from the backlog, but it is not a capacity
+	// eviction and must not be counted as one.
+	impl := filter.(*spanPropertiesFilter)
+	assert.Equal(t, int64(0), impl.SecondChanceEvictions())
+}
+
+func TestSecondChanceCache_CapacityEvictionIsCounted(t *testing.T) {
+	pattern := "^foo$"
+	size := 1
+	filter, err := NewSpanPropertiesFilter(zap.NewNop(), SpanPropertiesFilterConfig{
+		OperationNamePattern:  &pattern,
+		SecondChanceCacheSize: &size,
+	})
+	require.NoError(t, err)
+
+	bar := spanWithTimes(0, 1)
+	bar.Name = &tracepb.TruncatableString{Value: "bar"}
+
+	_, err = filter.Evaluate(traceIDFromByte(1), newTraceData(bar))
+	require.NoError(t, err)
+	_, err = filter.Evaluate(traceIDFromByte(2), newTraceData(bar))
+	require.NoError(t, err)
+
+	impl := filter.(*spanPropertiesFilter)
+	assert.Equal(t, int64(1), impl.SecondChanceEvictions())
+	assert.Equal(t, int64(0), impl.SecondChanceFlipped())
+}
@@ -0,0 +1,70 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+var (
+	statSecondChanceEvaluations = stats.Int64(
+		"sampling_second_chance_evaluations",
+		"Number of times a previously not-sampled trace was re-evaluated via the second-chance backlog",
+		stats.UnitDimensionless)
+	statSecondChanceFlipped = stats.Int64(
+		"sampling_second_chance_flipped",
+		"Number of traces whose decision flipped from not-sampled to sampled during second-chance reconsideration",
+		stats.UnitDimensionless)
+	statSecondChanceEvictions = stats.Int64(
+		"sampling_second_chance_evictions",
+		"Number of trace IDs dropped from the second-chance backlog due to capacity",
+		stats.UnitDimensionless)
+)
+
+func init() {
+	views := []*view.View{
+		{
+			Name:        statSecondChanceEvaluations.Name(),
+			Measure:     statSecondChanceEvaluations,
+			Description: statSecondChanceEvaluations.Description(),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        statSecondChanceFlipped.Name(),
+			Measure:     statSecondChanceFlipped,
+			Description: statSecondChanceFlipped.Description(),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        statSecondChanceEvictions.Name(),
+			Measure:     statSecondChanceEvictions,
+			Description: statSecondChanceEvictions.Description(),
+			Aggregation: view.Count(),
+		},
+	}
+	if err := view.Register(views...); err != nil {
+		panic(err)
+	}
+}
+
+// recordSecondChanceMeasure records a single occurrence of measure against
+// the default, unattributed context. The second-chance backlog is not
+// per-request, so there is no incoming context to propagate tags from.
+func recordSecondChanceMeasure(measure *stats.Int64Measure) {
+	stats.Record(context.Background(), measure.M(1))
+}
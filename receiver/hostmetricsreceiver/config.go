@@ -15,6 +15,13 @@
 package hostmetricsreceiver
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/multierr"
+
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/receiver/hostmetricsreceiver/internal"
 	"go.opentelemetry.io/collector/receiver/scraperhelper"
@@ -28,7 +35,66 @@ type Config struct {
 
 var _ config.Receiver = (*Config)(nil)
 
+// validatableScraperConfig is implemented by scraper configs that want to
+// validate their own fields (e.g. filesystem include/exclude patterns,
+// process include lists). A config that doesn't implement it is treated as
+// valid by default.
+//
+// The concrete scraper config types (filesystemscraper, processscraper,
+// networkscraper, etc.) that would implement this are not part of this
+// checkout, so this change only adds the plumbing; wiring up real
+// validation on each scraper's own config is tracked separately.
+type validatableScraperConfig interface {
+	Validate() error
+}
+
+// minCollectionIntervalScraperConfig is implemented by scraper configs that
+// cannot meaningfully collect faster than some scraper-specific interval.
+type minCollectionIntervalScraperConfig interface {
+	MinCollectionInterval() time.Duration
+}
+
 // Validate checks the receiver configuration is valid
 func (cfg *Config) Validate() error {
-	return nil
+	var errs error
+
+	if len(cfg.Scrapers) == 0 {
+		errs = multierr.Append(errs, errors.New("must specify at least one scraper when using hostmetrics receiver"))
+	}
+
+	if cfg.CollectionInterval <= 0 {
+		errs = multierr.Append(errs, fmt.Errorf("collection_interval must be a positive duration, got %s", cfg.CollectionInterval))
+	}
+
+	for key, scraperCfg := range cfg.Scrapers {
+		if _, ok := scraperFactories[key]; !ok {
+			errs = multierr.Append(errs, fmt.Errorf("%s is not a supported scraper, known scrapers: %v", key, knownScraperNames()))
+			continue
+		}
+
+		if v, ok := scraperCfg.(validatableScraperConfig); ok {
+			if err := v.Validate(); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+
+		if m, ok := scraperCfg.(minCollectionIntervalScraperConfig); ok {
+			if min := m.MinCollectionInterval(); min > 0 && cfg.CollectionInterval < min {
+				errs = multierr.Append(errs, fmt.Errorf("%s: collection_interval must be at least %s", key, min))
+			}
+		}
+	}
+
+	return errs
+}
+
+// knownScraperNames returns the names of the registered scraper factories,
+// sorted for stable, readable error messages.
+func knownScraperNames() []string {
+	names := make([]string, 0, len(scraperFactories))
+	for name := range scraperFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,112 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostmetricsreceiver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+
+	"go.opentelemetry.io/collector/receiver/hostmetricsreceiver/internal"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+type okScraperConfig struct{}
+
+type invalidScraperConfig struct{}
+
+func (invalidScraperConfig) Validate() error {
+	return errors.New("bad config")
+}
+
+type minIntervalScraperConfig struct{}
+
+func (minIntervalScraperConfig) MinCollectionInterval() time.Duration {
+	return time.Second
+}
+
+func TestValidate_NoScrapers(t *testing.T) {
+	cfg := &Config{
+		ScraperControllerSettings: scraperhelper.ScraperControllerSettings{CollectionInterval: time.Second},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Len(t, multierr.Errors(err), 1)
+}
+
+func TestValidate_NonPositiveCollectionInterval(t *testing.T) {
+	cfg := &Config{
+		ScraperControllerSettings: scraperhelper.ScraperControllerSettings{CollectionInterval: 0},
+		Scrapers:                  map[string]internal.Config{"cpu": okScraperConfig{}},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+}
+
+func TestValidate_UnknownScraper(t *testing.T) {
+	cfg := &Config{
+		ScraperControllerSettings: scraperhelper.ScraperControllerSettings{CollectionInterval: time.Second},
+		Scrapers:                  map[string]internal.Config{"not-a-real-scraper": okScraperConfig{}},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not-a-real-scraper")
+}
+
+func TestValidate_PerScraperValidateError(t *testing.T) {
+	cfg := &Config{
+		ScraperControllerSettings: scraperhelper.ScraperControllerSettings{CollectionInterval: time.Second},
+		Scrapers:                  map[string]internal.Config{"cpu": invalidScraperConfig{}},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad config")
+}
+
+func TestValidate_PerScraperMinCollectionInterval(t *testing.T) {
+	cfg := &Config{
+		ScraperControllerSettings: scraperhelper.ScraperControllerSettings{CollectionInterval: 10 * time.Millisecond},
+		Scrapers:                  map[string]internal.Config{"cpu": minIntervalScraperConfig{}},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "collection_interval must be at least")
+}
+
+func TestValidate_AggregatesAllErrors(t *testing.T) {
+	cfg := &Config{
+		ScraperControllerSettings: scraperhelper.ScraperControllerSettings{CollectionInterval: 0},
+		Scrapers: map[string]internal.Config{
+			"cpu":                invalidScraperConfig{},
+			"not-a-real-scraper": okScraperConfig{},
+		},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	// non-positive interval + invalid cpu config + unknown scraper == 3 errors.
+	assert.Len(t, multierr.Errors(err), 3)
+}
+
+func TestValidate_Valid(t *testing.T) {
+	cfg := &Config{
+		ScraperControllerSettings: scraperhelper.ScraperControllerSettings{CollectionInterval: time.Second},
+		Scrapers:                  map[string]internal.Config{"cpu": okScraperConfig{}, "memory": okScraperConfig{}},
+	}
+	assert.NoError(t, cfg.Validate())
+}